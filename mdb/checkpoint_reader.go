@@ -0,0 +1,60 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// CheckpointRecord is one decoded line from a keyhole_perf NDJSON
+// checkpoint: a single sampling tick for one op on one uri/thread
+type CheckpointRecord struct {
+	Ts        time.Time `json:"ts"`
+	URI       string    `json:"uri"`
+	Thread    int       `json:"thread"`
+	Op        string    `json:"op"`
+	LatencyNs int64     `json:"latency_ns"`
+}
+
+// ReadCheckpoint decodes every record from a keyhole_perf jsonl.gz
+// checkpoint (a gzipped file of one JSON record per sampling tick) so a
+// partial or still-growing file left behind by a killed run can be
+// replayed to resume metric aggregation. A truncated trailing record,
+// expected if the writer was killed mid-flush, is skipped rather than
+// treated as a fatal error.
+func ReadCheckpoint(filename string) ([]CheckpointRecord, error) {
+	var records []CheckpointRecord
+	file, err := os.Open(filename)
+	if err != nil {
+		return records, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return records, err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec CheckpointRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil && err != io.ErrUnexpectedEOF {
+		return records, err
+	}
+	return records, nil
+}
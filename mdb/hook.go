@@ -0,0 +1,130 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Level represents a log entry's severity, lowest to highest
+type Level int
+
+// severities accepted by Logger.WithLevel and carried on every LogEntry
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+// String returns the name of a Level
+func (level Level) String() string {
+	switch level {
+	case DebugLevel:
+		return "debug"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// MarshalJSON renders a Level as its name (e.g. "warn") rather than its
+// underlying int, so every hook that JSON-encodes a LogEntry agrees on
+// the same representation
+func (level Level) MarshalJSON() ([]byte, error) {
+	return json.Marshal(level.String())
+}
+
+// LogEntry is the record handed to every Hook registered on a Logger
+type LogEntry struct {
+	Level   Level                  `json:"level"`
+	Time    time.Time              `json:"time"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Hook receives a copy of every LogEntry that passes a Logger's level filter.
+// Hooks that buffer or hold open resources (files, connections) should also
+// implement io.Closer so Logger.Close() can drain them on shutdown.
+type Hook interface {
+	Fire(entry LogEntry) error
+}
+
+// AddHook registers a Hook to receive future log entries
+func (p *Logger) AddHook(hook Hook) {
+	p.hookMutex.Lock()
+	defer p.hookMutex.Unlock()
+	p.hooks = append(p.hooks, hook)
+}
+
+// WithLevel sets the minimum level an entry must meet to reach the hooks
+func (p *Logger) WithLevel(level Level) *Logger {
+	p.level = level
+	return p
+}
+
+// WithFields returns an Entry carrying additional structured fields that
+// will be attached to the next message logged through it
+func (p *Logger) WithFields(fields map[string]interface{}) *Entry {
+	return &Entry{logger: p, fields: fields}
+}
+
+// Entry pairs a Logger with a set of fields, logrus-style
+type Entry struct {
+	logger *Logger
+	fields map[string]interface{}
+}
+
+// Debug fires a debug level entry
+func (e *Entry) Debug(message string) { e.logger.fire(DebugLevel, message, e.fields) }
+
+// Info fires an info level entry
+func (e *Entry) Info(message string) { e.logger.fire(InfoLevel, message, e.fields) }
+
+// Warn fires a warn level entry
+func (e *Entry) Warn(message string) { e.logger.fire(WarnLevel, message, e.fields) }
+
+// Error fires an error level entry
+func (e *Entry) Error(message string) { e.logger.fire(ErrorLevel, message, e.fields) }
+
+// fire filters entry by level, records it for Print(), and runs every hook
+func (p *Logger) fire(level Level, message string, fields map[string]interface{}) {
+	if level < p.level {
+		return
+	}
+	entry := LogEntry{Level: level, Time: time.Now(), Message: message, Fields: fields}
+	p.hookMutex.Lock()
+	if level >= WarnLevel {
+		p.Warnings = append(p.Warnings, message)
+	} else {
+		p.Logs = append(p.Logs, entry.Time.Format(time.RFC3339)+" "+message)
+	}
+	hooks := append([]Hook{}, p.hooks...)
+	p.hookMutex.Unlock()
+	for _, hook := range hooks {
+		if err := hook.Fire(entry); err != nil {
+			log.Println("hook error:", err)
+		}
+	}
+}
+
+// Close flushes and closes every hook that implements io.Closer, letting a
+// long-running process drain buffered hooks (file, MongoDB) before exit
+func (p *Logger) Close() error {
+	p.hookMutex.Lock()
+	defer p.hookMutex.Unlock()
+	var firstErr error
+	for _, hook := range p.hooks {
+		if closer, ok := hook.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
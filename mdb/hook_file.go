@@ -0,0 +1,83 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultMaxFileHookBytes is the size at which FileHook rotates its output
+const defaultMaxFileHookBytes = 10 * 1024 * 1024
+
+// FileHook appends JSON-encoded LogEntry records to a file, one per line,
+// rotating the file to a timestamped backup once it exceeds maxSizeBytes.
+type FileHook struct {
+	path         string
+	maxSizeBytes int64
+	mutex        sync.Mutex
+	file         *os.File
+}
+
+// NewFileHook opens (creating if necessary) path for append and returns a
+// Hook that writes to it, rotating once it grows past maxSizeBytes. A
+// maxSizeBytes of 0 uses a 10MB default.
+func NewFileHook(path string, maxSizeBytes int64) (*FileHook, error) {
+	if maxSizeBytes <= 0 {
+		maxSizeBytes = defaultMaxFileHookBytes
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileHook{path: path, maxSizeBytes: maxSizeBytes, file: file}, nil
+}
+
+// Fire appends entry as a line of JSON, rotating the underlying file first
+// if it has grown past the configured limit
+func (h *FileHook) Fire(entry LogEntry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if err := h.rotateIfNeeded(); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.file.Write(append(buf, '\n'))
+	return err
+}
+
+func (h *FileHook) rotateIfNeeded() error {
+	info, err := h.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < h.maxSizeBytes {
+		return nil
+	}
+	if err := h.file.Close(); err != nil {
+		return err
+	}
+	backup := fmt.Sprintf("%v.%v", h.path, time.Now().Format("20060102T150405"))
+	if err := os.Rename(h.path, backup); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	h.file = file
+	return nil
+}
+
+// Close flushes and closes the underlying file
+func (h *FileHook) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.file.Close()
+}
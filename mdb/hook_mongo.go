@@ -0,0 +1,97 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultLogsCollection is the capped collection MongoHook writes to when
+// no collection name is given
+const DefaultLogsCollection = "logs"
+
+const defaultMongoHookBufferSize = 256
+const defaultMongoHookCappedBytes = 64 * 1024 * 1024
+
+// MongoHook buffers LogEntry records and inserts them asynchronously into a
+// capped keyhole.logs collection, so a long-running keyhole run against a
+// production cluster leaves its warnings and progress behind even if the
+// process is later killed.
+type MongoHook struct {
+	collection *mongo.Collection
+	buffer     chan LogEntry
+	done       chan struct{}
+	mutex      sync.Mutex
+	closed     bool
+}
+
+// NewMongoHook ensures a capped collection exists in dbName and returns a
+// Hook that inserts entries into it from a background goroutine. An empty
+// collectionName defaults to DefaultLogsCollection.
+func NewMongoHook(client *mongo.Client, dbName string, collectionName string, bufferSize int) (*MongoHook, error) {
+	if collectionName == "" {
+		collectionName = DefaultLogsCollection
+	}
+	if bufferSize <= 0 {
+		bufferSize = defaultMongoHookBufferSize
+	}
+	ctx := context.Background()
+	opts := options.CreateCollection().SetCapped(true).SetSizeInBytes(defaultMongoHookCappedBytes)
+	if err := client.Database(dbName).CreateCollection(ctx, collectionName, opts); err != nil {
+		if cmdErr, ok := err.(mongo.CommandError); !ok || cmdErr.Name != "NamespaceExists" {
+			return nil, err
+		}
+	}
+	hook := &MongoHook{
+		collection: client.Database(dbName).Collection(collectionName),
+		buffer:     make(chan LogEntry, bufferSize),
+		done:       make(chan struct{}),
+	}
+	go hook.loop()
+	return hook, nil
+}
+
+// Fire queues entry for asynchronous insertion. If the buffer is full the
+// entry is dropped rather than stalling the caller. A concurrent caller may
+// still be firing while Close runs, so closed is checked under the same
+// mutex Close uses to set it and close the buffer, rather than sending on a
+// channel that could already be closed.
+func (h *MongoHook) Fire(entry LogEntry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return nil
+	}
+	select {
+	case h.buffer <- entry:
+	default:
+	}
+	return nil
+}
+
+func (h *MongoHook) loop() {
+	defer close(h.done)
+	ctx := context.Background()
+	for entry := range h.buffer {
+		doc := bson.M{"level": entry.Level.String(), "time": entry.Time, "message": entry.Message, "fields": entry.Fields}
+		if _, err := h.collection.InsertOne(ctx, doc); err != nil {
+			log.Println("mongo hook insert error:", err)
+		}
+	}
+}
+
+// Close flushes the buffer and blocks until the writer goroutine drains it
+func (h *MongoHook) Close() error {
+	h.mutex.Lock()
+	h.closed = true
+	close(h.buffer)
+	h.mutex.Unlock()
+	<-h.done
+	return nil
+}
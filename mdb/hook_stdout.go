@@ -0,0 +1,36 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StdoutHook prints each LogEntry at or above MinLevel as a single line of
+// JSON. Registered by default at WarnLevel, it replaces the old hardcoded
+// ANSI-colorized warning println with a structured sink that plays well
+// with log collectors, without turning every Add/Log call into console
+// output.
+type StdoutHook struct {
+	MinLevel Level
+}
+
+// NewStdoutHook returns a Hook that prints warn-and-above entries as JSON
+// to stdout
+func NewStdoutHook() *StdoutHook {
+	return &StdoutHook{MinLevel: WarnLevel}
+}
+
+// Fire marshals entry to JSON and prints it, skipping anything below MinLevel
+func (h *StdoutHook) Fire(entry LogEntry) error {
+	if entry.Level < h.MinLevel {
+		return nil
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(buf))
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,14 +18,19 @@ type Logger struct {
 	Params    string    `bson:"params"`
 	Version   string    `bson:"version"`
 
-	nocolor bool
+	nocolor   bool
+	level     Level
+	hooks     []Hook
+	hookMutex sync.Mutex
 }
 
-// NewLogger returns Logger
+// NewLogger returns Logger, pre-wired with a StdoutHook so entries keep
+// surfacing on the console the way Warn/Log always have
 func NewLogger(fullVersion string, params string) *Logger {
-	p := Logger{Version: fullVersion, Params: params, Warnings: []string{}}
+	p := Logger{Version: fullVersion, Params: params, Warnings: []string{}, level: InfoLevel}
 	p.Collected = time.Now()
 	p.Logs = []string{fmt.Sprintf(`%v keyhole begins`, p.Collected.Format(time.RFC3339))}
+	p.AddHook(NewStdoutHook())
 	return &p
 }
 
@@ -35,20 +41,19 @@ func (p *Logger) SetNoColor(nocolor bool) {
 
 // Add adds a message
 func (p *Logger) Add(message string) {
-	str := fmt.Sprintf(`%v %v`, time.Now().Format(time.RFC3339), message)
-	p.Logs = append(p.Logs, str)
+	p.fire(InfoLevel, message, nil)
 }
 
-// Warn adds an warning message
+// Warn adds an warning message and fires it to registered hooks (the
+// default StdoutHook renders it, replacing the old hardcoded colorized
+// println)
 func (p *Logger) Warn(message string) {
-	p.Warnings = append(p.Warnings, message)
-	fmt.Println(codeRed, "*", message, codeDefault)
+	p.fire(WarnLevel, message, nil)
 }
 
 // Log adds and prints a message
 func (p *Logger) Log(message string) {
-	str := fmt.Sprintf(`%v %v`, time.Now().Format(time.RFC3339), message)
-	p.Logs = append(p.Logs, str)
+	p.fire(InfoLevel, message, nil)
 	log.Println(message)
 }
 
@@ -57,11 +62,15 @@ func (p *Logger) Print() string {
 	if p == nil {
 		return ""
 	}
+	p.hookMutex.Lock()
+	logs := append([]string{}, p.Logs...)
+	warnings := append([]string{}, p.Warnings...)
+	p.hookMutex.Unlock()
 	strs := []string{fmt.Sprintf(`{ keyhole: { version: "%v", args: "%v" } }`, p.Version, p.Params)}
-	strs = append(strs, p.Logs...)
-	if len(p.Warnings) > 0 {
+	strs = append(strs, logs...)
+	if len(warnings) > 0 {
 		strs = append(strs, "\nWarnings:")
-		for _, warning := range p.Warnings {
+		for _, warning := range warnings {
 			if p.nocolor {
 				strs = append(strs, warning)
 			} else {
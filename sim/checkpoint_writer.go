@@ -0,0 +1,127 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// metricRecord is one NDJSON line appended to a keyhole_perf checkpoint
+// file: a single sampling tick for one op on one uri/thread
+type metricRecord struct {
+	Ts        time.Time `json:"ts"`
+	URI       string    `json:"uri"`
+	Thread    int       `json:"thread"`
+	Op        string    `json:"op"`
+	LatencyNs int64     `json:"latency_ns"`
+}
+
+// checkpointWriter appends metricRecords to a gzipped NDJSON file from a
+// single background goroutine, so a crash or SIGKILL mid-run only loses
+// whatever hasn't been flushed yet instead of the entire keyhole_perf dump.
+type checkpointWriter struct {
+	records chan metricRecord
+	done    chan struct{}
+	file    *os.File
+	gz      *gzip.Writer
+	bw      *bufio.Writer
+	mutex   sync.Mutex
+	closed  bool
+}
+
+// newCheckpointWriter opens (or resumes appending to) filename and starts
+// the background writer goroutine
+func newCheckpointWriter(filename string) (*checkpointWriter, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	w := &checkpointWriter{
+		records: make(chan metricRecord, 1024),
+		done:    make(chan struct{}),
+		file:    file,
+		gz:      gz,
+		bw:      bufio.NewWriter(gz),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *checkpointWriter) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case rec, ok := <-w.records:
+			if !ok {
+				w.flush()
+				return
+			}
+			w.append(rec)
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+func (w *checkpointWriter) append(rec metricRecord) {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		log.Println("checkpoint marshal error:", err)
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.bw.Write(buf)
+	w.bw.WriteByte('\n')
+}
+
+func (w *checkpointWriter) flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.bw.Flush(); err != nil {
+		log.Println("checkpoint flush error:", err)
+		return
+	}
+	if err := w.gz.Flush(); err != nil {
+		log.Println("checkpoint flush error:", err)
+	}
+}
+
+// Write queues rec for the background writer; it never waits on a full
+// queue, dropping the record instead if the writer has fallen behind. Simulate
+// threads may still be calling Write concurrently with Close, so closed is
+// checked under the same mutex that Close sets it and closes records under,
+// rather than sending on a channel that could already be closed.
+func (w *checkpointWriter) Write(rec metricRecord) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.records <- rec:
+	default:
+	}
+}
+
+// Close flushes remaining records and closes the underlying gzip file
+func (w *checkpointWriter) Close() error {
+	w.mutex.Lock()
+	w.closed = true
+	close(w.records)
+	w.mutex.Unlock()
+	<-w.done
+	if err := w.gz.Close(); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
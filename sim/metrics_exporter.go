@@ -0,0 +1,306 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// opSample is a single observed transaction outcome, fed into the
+// MetricsExporter's aggregation channel from the per-thread simulate loop
+type opSample struct {
+	thread  int
+	op      string
+	latency time.Duration
+	err     error
+}
+
+// opKey identifies one per-thread, per-op series
+type opKey struct {
+	thread int
+	op     string
+}
+
+// opMetrics aggregates samples for one opKey within the current window; it
+// is snapshotted and reset every scrape/push so TPS reflects the current
+// window rather than a lifetime average
+type opMetrics struct {
+	count     int64
+	errors    int64
+	latencies []float64 // nanoseconds
+}
+
+// MetricsExporter serves live load-test telemetry (per-thread TPS, per-op
+// latency percentiles, error counts, connection pool saturation) over HTTP
+// while a Runner's Simulate() is in flight, either for Prometheus to scrape
+// or pushed to InfluxDB as line protocol, mirroring how telegraf-style
+// agents ship metrics. Prometheus and InfluxDB each get their own window
+// (scrapeOps/influxOps, reset independently on their own read) so the two
+// outputs never race to reset a shared accumulator out from under each other.
+type MetricsExporter struct {
+	addr           string
+	influxURL      string
+	influxPeriod   time.Duration
+	server         *http.Server
+	mutex          sync.Mutex
+	scrapeOps      map[opKey]*opMetrics
+	influxOps      map[opKey]*opMetrics
+	conns          int
+	connsInUse     int
+	lastScrape     time.Time
+	lastInfluxPush time.Time
+	stopCh         chan struct{}
+}
+
+// NewMetricsExporter returns a MetricsExporter that will listen on addr
+func NewMetricsExporter(addr string) *MetricsExporter {
+	now := time.Now()
+	return &MetricsExporter{
+		addr:           addr,
+		influxPeriod:   10 * time.Second,
+		scrapeOps:      map[opKey]*opMetrics{},
+		influxOps:      map[opKey]*opMetrics{},
+		lastScrape:     now,
+		lastInfluxPush: now,
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// SetInfluxDBURL configures a target such as influxdb://host:8086/db that
+// the exporter periodically pushes the same series to as line protocol
+func (e *MetricsExporter) SetInfluxDBURL(url string) {
+	e.influxURL = url
+}
+
+// SetConnPoolSize records the configured connection pool size so saturation
+// can be reported as connsInUse/conns
+func (e *MetricsExporter) SetConnPoolSize(conns int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.conns = conns
+}
+
+// IncConnsInUse marks one more connection as actively running a transaction
+func (e *MetricsExporter) IncConnsInUse() {
+	e.mutex.Lock()
+	e.connsInUse++
+	e.mutex.Unlock()
+}
+
+// DecConnsInUse marks a connection as idle again
+func (e *MetricsExporter) DecConnsInUse() {
+	e.mutex.Lock()
+	e.connsInUse--
+	e.mutex.Unlock()
+}
+
+// record folds a sample into both the scrape and influx windows for its
+// thread/op; the two consumers never share an accumulator so one resetting
+// its window on read can't zero out the other's data
+func (e *MetricsExporter) record(sample opSample) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	key := opKey{thread: sample.thread, op: sample.op}
+	fold(e.scrapeOps, key, sample)
+	fold(e.influxOps, key, sample)
+}
+
+func fold(ops map[opKey]*opMetrics, key opKey, sample opSample) {
+	m, ok := ops[key]
+	if !ok {
+		m = &opMetrics{}
+		ops[key] = m
+	}
+	m.count++
+	if sample.err != nil {
+		m.errors++
+	}
+	m.latencies = append(m.latencies, float64(sample.latency.Nanoseconds()))
+}
+
+// snapshotAndReset returns a copy of ops (plus the duration since last) and
+// clears ops so the next window starts empty; this keeps reported TPS a live
+// rate instead of a lifetime average and bounds memory. Caller passes its
+// own ops map (scrapeOps or influxOps) so resetting one consumer's window
+// never affects the other's.
+func (e *MetricsExporter) snapshotAndReset(ops map[opKey]*opMetrics, last *time.Time) (map[opKey]opMetrics, float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	elapsed := time.Since(*last).Seconds()
+	if elapsed <= 0 {
+		elapsed = 1
+	}
+	*last = time.Now()
+	snapshot := make(map[opKey]opMetrics, len(ops))
+	for key, m := range ops {
+		if m.count == 0 {
+			delete(ops, key)
+			continue
+		}
+		snapshot[key] = *m
+		ops[key] = &opMetrics{}
+	}
+	return snapshot, elapsed
+}
+
+// Run drains samples until the channel is closed or Stop() is called. The
+// /metrics server it starts keeps serving until Stop(). It's meant to run in
+// its own goroutine.
+func (e *MetricsExporter) Run(samples <-chan opSample) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.handlePrometheus)
+	e.server = &http.Server{Addr: e.addr, Handler: mux}
+	go func() {
+		if err := e.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Println("metrics exporter:", err)
+		}
+	}()
+
+	var influxTicker *time.Ticker
+	var influxC <-chan time.Time
+	if e.influxURL != "" {
+		influxTicker = time.NewTicker(e.influxPeriod)
+		influxC = influxTicker.C
+		defer influxTicker.Stop()
+	}
+
+	for {
+		select {
+		case sample, ok := <-samples:
+			if !ok {
+				return
+			}
+			e.record(sample)
+		case <-influxC:
+			if err := e.pushInfluxDB(); err != nil {
+				log.Println("influxdb push error:", err)
+			}
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// Stop gracefully shuts down the embedded HTTP server
+func (e *MetricsExporter) Stop() error {
+	close(e.stopCh)
+	if e.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return e.server.Shutdown(ctx)
+}
+
+func (e *MetricsExporter) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(e.render()))
+}
+
+// render produces the Prometheus text exposition format for the window
+// since the last scrape: per-thread, per-op TPS and latency percentiles,
+// error counts, and connection pool saturation
+func (e *MetricsExporter) render() string {
+	window, elapsed := e.snapshotAndReset(e.scrapeOps, &e.lastScrape)
+	var buf bytes.Buffer
+	buf.WriteString("# HELP keyhole_op_tps transactions per second by thread and op type\n")
+	buf.WriteString("# TYPE keyhole_op_tps gauge\n")
+	buf.WriteString("# HELP keyhole_op_errors errors observed in the current window by thread and op type\n")
+	buf.WriteString("# TYPE keyhole_op_errors gauge\n")
+	buf.WriteString("# HELP keyhole_op_latency_seconds latency percentiles by thread and op type\n")
+	buf.WriteString("# TYPE keyhole_op_latency_seconds summary\n")
+	for key, m := range window {
+		tps := float64(m.count) / elapsed
+		fmt.Fprintf(&buf, "keyhole_op_tps{op=%q,thread=\"%d\"} %v\n", key.op, key.thread, tps)
+		fmt.Fprintf(&buf, "keyhole_op_errors{op=%q,thread=\"%d\"} %v\n", key.op, key.thread, m.errors)
+		p50, p95, p99 := percentiles(m.latencies)
+		fmt.Fprintf(&buf, "keyhole_op_latency_seconds{op=%q,thread=\"%d\",quantile=\"0.5\"} %v\n", key.op, key.thread, p50/1e9)
+		fmt.Fprintf(&buf, "keyhole_op_latency_seconds{op=%q,thread=\"%d\",quantile=\"0.95\"} %v\n", key.op, key.thread, p95/1e9)
+		fmt.Fprintf(&buf, "keyhole_op_latency_seconds{op=%q,thread=\"%d\",quantile=\"0.99\"} %v\n", key.op, key.thread, p99/1e9)
+	}
+	buf.WriteString("# HELP keyhole_conn_pool_saturation fraction of the connection pool in use\n")
+	buf.WriteString("# TYPE keyhole_conn_pool_saturation gauge\n")
+	e.mutex.Lock()
+	saturation := float64(0)
+	if e.conns > 0 {
+		saturation = float64(e.connsInUse) / float64(e.conns)
+	}
+	e.mutex.Unlock()
+	fmt.Fprintf(&buf, "keyhole_conn_pool_saturation %v\n", saturation)
+	return buf.String()
+}
+
+// pushInfluxDB writes the window since the last push to the configured
+// InfluxDB URL as line protocol, e.g. influxdb://host:8086/db
+func (e *MetricsExporter) pushInfluxDB() error {
+	host, db, err := parseInfluxDBURL(e.influxURL)
+	if err != nil {
+		return err
+	}
+	lines := e.influxLines()
+	if lines == "" {
+		return nil
+	}
+	writeURL := fmt.Sprintf("http://%v/write?db=%v", host, db)
+	resp, err := http.Post(writeURL, "text/plain", strings.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("influxdb write failed with status %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// influxLines renders the window since the last push as InfluxDB line
+// protocol
+func (e *MetricsExporter) influxLines() string {
+	window, elapsed := e.snapshotAndReset(e.influxOps, &e.lastInfluxPush)
+	now := time.Now().UnixNano()
+	var buf bytes.Buffer
+	for key, m := range window {
+		tps := float64(m.count) / elapsed
+		p50, p95, p99 := percentiles(m.latencies)
+		fmt.Fprintf(&buf, "keyhole_op,op=%v,thread=%d tps=%v,errors=%v,p50=%v,p95=%v,p99=%v %v\n",
+			key.op, key.thread, tps, m.errors, p50, p95, p99, now)
+	}
+	return buf.String()
+}
+
+// parseInfluxDBURL parses influxdb://host:8086/db into host:port and db
+func parseInfluxDBURL(url string) (host string, db string, err error) {
+	const scheme = "influxdb://"
+	if !strings.HasPrefix(url, scheme) {
+		return "", "", fmt.Errorf("invalid influxdb url: %v", url)
+	}
+	rest := strings.TrimPrefix(url, scheme)
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid influxdb url: %v", url)
+	}
+	return parts[0], parts[1], nil
+}
+
+// percentiles returns p50, p95, p99 (in nanoseconds) of a latency sample set
+func percentiles(latencies []float64) (p50 float64, p95 float64, p99 float64) {
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+	sorted := append([]float64{}, latencies...)
+	sort.Float64s(sorted)
+	return pick(sorted, 0.50), pick(sorted, 0.95), pick(sorted, 0.99)
+}
+
+func pick(sorted []float64, quantile float64) float64 {
+	idx := int(quantile * float64(len(sorted)-1))
+	return sorted[idx]
+}
@@ -37,12 +37,18 @@ type Runner struct {
 	drop           bool
 	duration       int
 	filename       string
+	logger         *mdb.Logger
 	metrics        map[string][]bson.M
+	metricsAddr    string
+	metricsExp     *MetricsExporter
+	opSamples      chan opSample
+	checkpoint     *checkpointWriter
 	mutex          sync.RWMutex
 	peek           bool
 	simOnly        bool
 	tps            int
 	txFilename     string
+	txFormat       string
 	uri            string
 	uriList        []string
 	verbose        bool
@@ -138,11 +144,118 @@ func (rn *Runner) SetTransactionTemplateFilename(filename string) {
 	rn.txFilename = filename
 }
 
+// SetTransactionFormat overrides the transaction template format otherwise
+// detected from the file's .json/.yaml/.yml extension (the --tx-format flag)
+func (rn *Runner) SetTransactionFormat(format string) {
+	rn.txFormat = format
+}
+
 // SetSimOnlyMode -
 func (rn *Runner) SetSimOnlyMode(mode bool) {
 	rn.simOnly = mode
 }
 
+// SetLogger attaches a logger whose hooks (file, MongoDB, ...) should be
+// drained before the runner exits
+func (rn *Runner) SetLogger(logger *mdb.Logger) {
+	rn.logger = logger
+}
+
+// SetMetricsAddr enables a live telemetry endpoint on addr (e.g. ":9414")
+// exposing per-thread TPS, op latency percentiles, error counts, and
+// connection pool saturation for Prometheus to scrape at /metrics
+func (rn *Runner) SetMetricsAddr(addr string) {
+	rn.metricsAddr = addr
+}
+
+// SetMetricsInfluxDBURL additionally pushes the same series to an InfluxDB
+// instance as line protocol, e.g. "influxdb://localhost:8086/keyhole"
+func (rn *Runner) SetMetricsInfluxDBURL(url string) {
+	rn.ensureMetricsExporter()
+	if rn.metricsExp != nil {
+		rn.metricsExp.SetInfluxDBURL(url)
+	}
+}
+
+// RecordOp reports a completed transaction's thread, op type, latency, and
+// outcome to the live metrics exporter and the keyhole_perf checkpoint
+// file; both are no-ops until SetMetricsAddr/Start have run, so the
+// per-thread simulate loop can call it unconditionally
+func (rn *Runner) RecordOp(thread int, op string, latency time.Duration, err error) {
+	if rn.opSamples != nil {
+		select {
+		case rn.opSamples <- opSample{thread: thread, op: op, latency: latency, err: err}:
+		default:
+		}
+	}
+	if rn.checkpoint != nil {
+		rn.checkpoint.Write(metricRecord{Ts: time.Now(), URI: rn.uri, Thread: thread, Op: op, LatencyNs: latency.Nanoseconds()})
+	}
+}
+
+// ensureMetricsExporter lazily starts the embedded metrics HTTP server the
+// first time it's needed; safe to call more than once
+func (rn *Runner) ensureMetricsExporter() {
+	if rn.metricsAddr == "" || rn.metricsExp != nil {
+		return
+	}
+	rn.metricsExp = NewMetricsExporter(rn.metricsAddr)
+	rn.metricsExp.SetConnPoolSize(rn.conns)
+	rn.opSamples = make(chan opSample, 1024)
+	go rn.metricsExp.Run(rn.opSamples)
+}
+
+// trackConnStart marks a connection as actively running a transaction for
+// the connection pool saturation gauge; a no-op until SetMetricsAddr runs
+func (rn *Runner) trackConnStart() {
+	if rn.metricsExp != nil {
+		rn.metricsExp.IncConnsInUse()
+	}
+}
+
+// trackConnEnd marks a connection as idle again
+func (rn *Runner) trackConnEnd() {
+	if rn.metricsExp != nil {
+		rn.metricsExp.DecConnsInUse()
+	}
+}
+
+// ensureCheckpoint lazily opens the incremental keyhole_perf NDJSON writer
+// so the per-thread simulate loop (through RecordOp) can append to it as
+// it goes instead of waiting for a single dump at terminate(); safe to call
+// more than once
+func (rn *Runner) ensureCheckpoint() {
+	if rn.checkpoint != nil {
+		return
+	}
+	filename := "keyhole_perf." + fileTimestamp + ".jsonl.gz"
+	writer, err := newCheckpointWriter(filename)
+	if err != nil {
+		log.Println("checkpoint writer error:", err)
+		return
+	}
+	rn.checkpoint = writer
+}
+
+// LoadCheckpoint reopens a keyhole_perf jsonl.gz checkpoint left behind by
+// a previous (possibly killed) run and replays its records through
+// RecordOp, so the live metrics exporter and a freshly (re)opened
+// checkpoint file both resume aggregation instead of starting cold. Its
+// records are per-op sampling ticks, not server-status snapshots, so they
+// are replayed rather than merged into rn.metrics.
+func (rn *Runner) LoadCheckpoint(path string) error {
+	records, err := mdb.ReadCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	rn.ensureMetricsExporter()
+	rn.ensureCheckpoint()
+	for _, rec := range records {
+		rn.RecordOp(rec.Thread, rec.Op, time.Duration(rec.LatencyNs), nil)
+	}
+	return nil
+}
+
 // Start process requests
 func (rn *Runner) Start() error {
 	var err error
@@ -159,14 +272,19 @@ func (rn *Runner) Start() error {
 		}
 	}
 	log.Println("Duration in minute(s):", rn.duration)
+	tdoc, err := GetTransactions(rn.txFilename, rn.txFormat)
+	if err != nil {
+		return err
+	}
 	if rn.dbName == "" || rn.dbName == "admin" || rn.dbName == "config" || rn.dbName == "local" {
 		rn.dbName = mdb.KeyholeDB // switch to _KEYHOLE_88800 database for load tests
 	}
 	if rn.drop {
 		rn.Cleanup()
 	}
+	rn.ensureMetricsExporter()
+	rn.ensureCheckpoint()
 	rn.initSimDocs()
-	tdoc := GetTransactions(rn.txFilename)
 	// Simulation mode
 	// 1st minute - build up data and memory
 	// 2nd and 3rd minutes - normal TPS ops
@@ -220,19 +338,44 @@ func (rn *Runner) terminate() {
 	for _, filename := range filenames {
 		log.Println("stats written to", filename)
 	}
-	filename = "keyhole_perf." + fileTimestamp + ".bson.gz"
-	var buf []byte
-	if buf, err = json.Marshal(rn.metrics); err != nil {
-		log.Println("marshal error:", err)
+	filename = "keyhole_perf." + fileTimestamp + ".jsonl.gz"
+	if rn.checkpoint != nil {
+		if err = rn.checkpoint.Close(); err != nil {
+			log.Println("checkpoint close error:", err)
+		}
+	}
+	log.Println("metrics written to", filename)
+	// rn.metrics may have been populated by server-status collection;
+	// persist it too so those aggregated metrics are never silently
+	// dropped at exit
+	if len(rn.metrics) > 0 {
+		var buf []byte
+		if buf, err = json.Marshal(rn.metrics); err != nil {
+			log.Println("marshal error:", err)
+		} else {
+			legacyFilename := "keyhole_perf." + fileTimestamp + ".bson.gz"
+			gox.OutputGzipped(buf, legacyFilename)
+			log.Println("metrics written to", legacyFilename)
+		}
+	}
+	if rn.logger != nil {
+		if err = rn.logger.Close(); err != nil {
+			log.Println("logger close error:", err)
+		}
+	}
+	if rn.metricsExp != nil {
+		if err = rn.metricsExp.Stop(); err != nil {
+			log.Println("metrics exporter stop error:", err)
+		}
 	}
-	gox.OutputGzipped(buf, filename)
-	log.Println("optime written to", filename)
 	os.Exit(0)
 }
 
 // CollectAllStatus collects all server stats
 func (rn *Runner) CollectAllStatus() error {
 	var err error
+	rn.ensureMetricsExporter()
+	rn.ensureCheckpoint()
 	for i, uri := range rn.uriList {
 		var client *mongo.Client
 		if client, err = mdb.NewMongoClient(uri, rn.connString.SSLCaFile, rn.connString.SSLClientCertificateKeyFile); err != nil {
@@ -0,0 +1,88 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Simulate runs the weighted transaction mix against rn.client for
+// duration minutes (0 runs until externally terminated), reporting every
+// completed op to RecordOp so the live metrics exporter and the
+// keyhole_perf checkpoint both see it as it happens. Its first two minutes
+// and its last minute run at rn.tps transactions per second; the minutes in
+// between burst with no delay.
+func (rn *Runner) Simulate(duration int, transactions []Transaction, thread int) error {
+	totalWeight := 0.0
+	for _, tx := range transactions {
+		totalWeight += tx.Weight
+	}
+	if totalWeight <= 0 {
+		return errors.New("simulate: no weighted transactions to run")
+	}
+
+	delay := time.Second
+	if rn.tps > 0 {
+		delay = time.Second / time.Duration(rn.tps)
+	}
+	begin := time.Now()
+	var deadline time.Time
+	if duration > 0 {
+		deadline = begin.Add(time.Duration(duration) * time.Minute)
+	}
+	for duration <= 0 || time.Now().Before(deadline) {
+		tx := pickTransaction(transactions, totalWeight)
+		rn.trackConnStart()
+		for _, op := range tx.Ops {
+			start := time.Now()
+			err := rn.execOp(op)
+			rn.RecordOp(thread, op.Op, time.Since(start), err)
+		}
+		rn.trackConnEnd()
+		minute := int(time.Since(begin) / time.Minute)
+		if duration <= 0 || minute < 2 || minute >= duration-1 {
+			time.Sleep(delay)
+		}
+	}
+	return nil
+}
+
+// pickTransaction chooses a transaction using its Weight as a relative
+// probability
+func pickTransaction(transactions []Transaction, totalWeight float64) Transaction {
+	r := rand.Float64() * totalWeight
+	for _, tx := range transactions {
+		r -= tx.Weight
+		if r <= 0 {
+			return tx
+		}
+	}
+	return transactions[len(transactions)-1]
+}
+
+// execOp runs a single transaction op against rn.client
+func (rn *Runner) execOp(op Op) error {
+	ctx := context.Background()
+	c := rn.client.Database(rn.dbName).Collection(op.Collection)
+	switch op.Op {
+	case "find":
+		return c.FindOne(ctx, op.Filter).Err()
+	case "insert":
+		_, err := c.InsertOne(ctx, op.Document)
+		return err
+	case "update":
+		_, err := c.UpdateOne(ctx, op.Filter, bson.M{"$set": op.Update})
+		return err
+	case "delete":
+		_, err := c.DeleteOne(ctx, op.Filter)
+		return err
+	default:
+		return fmt.Errorf("unsupported op: %v", op.Op)
+	}
+}
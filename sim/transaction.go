@@ -0,0 +1,122 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// Op is a single MongoDB operation within a Transaction
+type Op struct {
+	Collection string `json:"collection" yaml:"collection"`
+	Op         string `json:"op" yaml:"op"`
+	Filter     bson.M `json:"filter,omitempty" yaml:"filter,omitempty"`
+	Document   bson.M `json:"document,omitempty" yaml:"document,omitempty"`
+	Update     bson.M `json:"update,omitempty" yaml:"update,omitempty"`
+}
+
+// Transaction describes a single weighted transaction (a sequence of ops)
+// inside a transaction template, e.g. {Name: "find-by-email", Ops: [...], Weight: 3}
+type Transaction struct {
+	Name   string  `json:"name" yaml:"name"`
+	Ops    []Op    `json:"ops" yaml:"ops"`
+	Weight float64 `json:"weight" yaml:"weight"`
+}
+
+// TransactionDoc is the parsed contents of a transaction template: the
+// indexes to create before simulating and the weighted transactions to run
+type TransactionDoc struct {
+	Indexes      []bson.M      `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+	Transactions []Transaction `json:"transactions" yaml:"transactions"`
+}
+
+// GetTransactions reads and validates a transaction template from filename.
+// The format is detected from the .yaml/.yml/.json extension, or overridden
+// via format (the --tx-format flag); both parse into the same TransactionDoc
+// consumed by Runner.createIndexes and Runner.Simulate. TransactionDoc's
+// {indexes, transactions:[{name, ops, weight}]} shape is this package's own
+// schema (introduced alongside Simulate), not an extension of any prior
+// keyhole template; unknown-key strictness is YAML-only (see isYAMLFormat)
+// specifically so a JSON file carrying stray legacy keys still parses.
+func GetTransactions(filename string, format string) (TransactionDoc, error) {
+	doc := TransactionDoc{}
+	if filename == "" {
+		return doc, nil
+	}
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return doc, err
+	}
+	if isYAMLFormat(filename, format) {
+		dec := yaml.NewDecoder(bytes.NewReader(buf))
+		dec.KnownFields(true)
+		if err = dec.Decode(&doc); err != nil {
+			return doc, fmt.Errorf("invalid yaml transaction template %v: %v", filename, err)
+		}
+	} else {
+		// unknown-key checking is YAML-only: existing JSON templates may
+		// carry legacy/extra fields and must keep parsing unchanged
+		if err = json.Unmarshal(buf, &doc); err != nil {
+			return doc, fmt.Errorf("invalid json transaction template %v: %v", filename, err)
+		}
+	}
+	if err = doc.validate(); err != nil {
+		return doc, err
+	}
+	return doc, nil
+}
+
+// isYAMLFormat decides whether filename should be parsed as YAML, honoring
+// an explicit --tx-format override before falling back to the extension
+func isYAMLFormat(filename string, format string) bool {
+	format = strings.ToLower(format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+	return format == "yaml" || format == "yml"
+}
+
+// validate rejects templates missing required op fields or whose weights
+// don't sum to a usable value, so bad templates fail before any load starts
+func (doc TransactionDoc) validate() error {
+	if len(doc.Transactions) == 0 {
+		return errors.New("transaction template defines no transactions")
+	}
+	var total float64
+	for _, tx := range doc.Transactions {
+		if tx.Name == "" {
+			return errors.New("transaction template has a transaction with no name")
+		}
+		if len(tx.Ops) == 0 {
+			return fmt.Errorf("transaction %q defines no ops", tx.Name)
+		}
+		if tx.Weight < 0 {
+			return fmt.Errorf("transaction %q has a negative weight", tx.Name)
+		}
+		for i, op := range tx.Ops {
+			if op.Collection == "" {
+				return fmt.Errorf("transaction %q op %d is missing collection", tx.Name, i)
+			}
+			if op.Op == "" {
+				return fmt.Errorf("transaction %q op %d is missing op", tx.Name, i)
+			}
+			if op.Op == "insert" && op.Document == nil {
+				return fmt.Errorf("transaction %q op %d (insert) is missing document", tx.Name, i)
+			}
+		}
+		total += tx.Weight
+	}
+	if total <= 0 {
+		return errors.New("transaction weights must sum to a positive value")
+	}
+	return nil
+}